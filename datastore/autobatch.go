@@ -0,0 +1,172 @@
+package datastore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/customerio/homework/serve"
+)
+
+const defaultAutobatchThreshold = 1000
+
+// pendingWrite records the last write queued for a customer ID; repeated
+// updates to the same key coalesce into a single pending entry.
+type pendingWrite struct {
+	deleted  bool
+	customer *serve.Customer
+}
+
+// autobatch wraps a Backend and buffers writes in memory, coalescing
+// repeated updates to the same customer so only the latest one reaches the
+// backend when the buffer is flushed. Sync writes each pending entry through
+// its own Backend call; the entries are not grouped into one transaction.
+type autobatch struct {
+	mu sync.Mutex
+
+	backend   Backend
+	threshold int
+
+	pendingCustomers map[int]pendingWrite
+	pendingEvents    map[string]bool
+}
+
+// newAutobatch wraps backend with a write buffer that flushes automatically
+// once threshold pending operations have accumulated.
+func newAutobatch(backend Backend, threshold int) *autobatch {
+	if threshold <= 0 {
+		threshold = defaultAutobatchThreshold
+	}
+
+	return &autobatch{
+		backend:          backend,
+		threshold:        threshold,
+		pendingCustomers: make(map[int]pendingWrite),
+		pendingEvents:    make(map[string]bool),
+	}
+}
+
+func (a *autobatch) GetCustomer(ctx context.Context, id int) (*serve.Customer, error) {
+	a.mu.Lock()
+	if pending, ok := a.pendingCustomers[id]; ok {
+		a.mu.Unlock()
+
+		if pending.deleted {
+			return nil, errCustomerNotFound
+		}
+
+		return pending.customer, nil
+	}
+	a.mu.Unlock()
+
+	return a.backend.GetCustomer(ctx, id)
+}
+
+func (a *autobatch) PutCustomer(ctx context.Context, customer *serve.Customer) error {
+	a.mu.Lock()
+	a.pendingCustomers[customer.ID] = pendingWrite{customer: customer}
+	full := len(a.pendingCustomers)+len(a.pendingEvents) >= a.threshold
+	a.mu.Unlock()
+
+	if full {
+		return a.Sync(ctx)
+	}
+
+	return nil
+}
+
+func (a *autobatch) DeleteCustomer(ctx context.Context, id int) error {
+	a.mu.Lock()
+	a.pendingCustomers[id] = pendingWrite{deleted: true}
+	full := len(a.pendingCustomers)+len(a.pendingEvents) >= a.threshold
+	a.mu.Unlock()
+
+	if full {
+		return a.Sync(ctx)
+	}
+
+	return nil
+}
+
+// IterateCustomers flushes pending writes first so iteration observes a
+// consistent view of the backend.
+func (a *autobatch) IterateCustomers(ctx context.Context, fn func(*serve.Customer) error) error {
+	if err := a.Sync(ctx); err != nil {
+		return err
+	}
+
+	return a.backend.IterateCustomers(ctx, fn)
+}
+
+func (a *autobatch) MarkEventProcessed(ctx context.Context, eventID string) error {
+	a.mu.Lock()
+	a.pendingEvents[eventID] = true
+	full := len(a.pendingCustomers)+len(a.pendingEvents) >= a.threshold
+	a.mu.Unlock()
+
+	if full {
+		return a.Sync(ctx)
+	}
+
+	return nil
+}
+
+func (a *autobatch) HasProcessedEvent(ctx context.Context, eventID string) (bool, error) {
+	a.mu.Lock()
+	if processed, ok := a.pendingEvents[eventID]; ok {
+		a.mu.Unlock()
+		return processed, nil
+	}
+	a.mu.Unlock()
+
+	return a.backend.HasProcessedEvent(ctx, eventID)
+}
+
+// IterateProcessedEvents flushes pending writes first so iteration observes
+// a consistent view of the backend.
+func (a *autobatch) IterateProcessedEvents(ctx context.Context, fn func(eventID string) error) error {
+	if err := a.Sync(ctx); err != nil {
+		return err
+	}
+
+	return a.backend.IterateProcessedEvents(ctx, fn)
+}
+
+// Sync flushes all buffered writes through to the underlying backend. Callers
+// invoke it explicitly once the stream has finished replaying and again on
+// graceful shutdown so nothing buffered is lost.
+func (a *autobatch) Sync(ctx context.Context) error {
+	a.mu.Lock()
+	customers := a.pendingCustomers
+	events := a.pendingEvents
+	a.pendingCustomers = make(map[int]pendingWrite)
+	a.pendingEvents = make(map[string]bool)
+	a.mu.Unlock()
+
+	for id, write := range customers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var err error
+		if write.deleted {
+			err = a.backend.DeleteCustomer(ctx, id)
+		} else {
+			err = a.backend.PutCustomer(ctx, write.customer)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for eventID := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := a.backend.MarkEventProcessed(ctx, eventID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/customerio/homework/serve"
+)
+
+var errCustomerNotFound = errors.New("customer not found")
+
+// Backend is the persistence layer beneath Datastore. Swapping
+// implementations (in-memory, BoltDB-backed, ...) lets the same ingestion
+// and query logic in Datastore run unchanged against different storage
+// engines.
+type Backend interface {
+	GetCustomer(ctx context.Context, id int) (*serve.Customer, error)
+	PutCustomer(ctx context.Context, customer *serve.Customer) error
+	DeleteCustomer(ctx context.Context, id int) error
+	IterateCustomers(ctx context.Context, fn func(*serve.Customer) error) error
+	MarkEventProcessed(ctx context.Context, eventID string) error
+	HasProcessedEvent(ctx context.Context, eventID string) (bool, error)
+
+	// IterateProcessedEvents walks every processed event ID. It exists
+	// mainly to support snapshotting the backend's dedupe state.
+	IterateProcessedEvents(ctx context.Context, fn func(eventID string) error) error
+}
+
+// memoryBackend is the original Backend implementation: everything lives in
+// process memory, so it starts empty on every run and keeps nothing on exit.
+type memoryBackend struct {
+	mu sync.RWMutex
+
+	customers       map[int]*serve.Customer
+	processedEvents map[string]bool
+}
+
+// newMemoryBackend returns an empty in-memory Backend.
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{
+		customers:       make(map[int]*serve.Customer),
+		processedEvents: make(map[string]bool),
+	}
+}
+
+// NewMemoryBackend returns a Backend that keeps all data in process memory.
+// It starts empty on every run.
+func NewMemoryBackend() Backend {
+	return newMemoryBackend()
+}
+
+func (b *memoryBackend) GetCustomer(ctx context.Context, id int) (*serve.Customer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	customer, exists := b.customers[id]
+	if !exists {
+		return nil, errCustomerNotFound
+	}
+
+	return customer, nil
+}
+
+func (b *memoryBackend) PutCustomer(ctx context.Context, customer *serve.Customer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.customers[customer.ID] = customer
+	return nil
+}
+
+func (b *memoryBackend) DeleteCustomer(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.customers[id]; !exists {
+		return errCustomerNotFound
+	}
+
+	delete(b.customers, id)
+	return nil
+}
+
+// IterateCustomers walks customers in ascending ID order so callers get a
+// stable, reproducible iteration regardless of map ordering.
+func (b *memoryBackend) IterateCustomers(ctx context.Context, fn func(*serve.Customer) error) error {
+	b.mu.RLock()
+	ids := make([]int, 0, len(b.customers))
+	for id := range b.customers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	customers := make([]*serve.Customer, 0, len(ids))
+	for _, id := range ids {
+		customers = append(customers, b.customers[id])
+	}
+	b.mu.RUnlock()
+
+	for _, customer := range customers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(customer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *memoryBackend) MarkEventProcessed(ctx context.Context, eventID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.processedEvents[eventID] = true
+	return nil
+}
+
+func (b *memoryBackend) HasProcessedEvent(ctx context.Context, eventID string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.processedEvents[eventID], nil
+}
+
+func (b *memoryBackend) IterateProcessedEvents(ctx context.Context, fn func(eventID string) error) error {
+	b.mu.RLock()
+	eventIDs := make([]string, 0, len(b.processedEvents))
+	for eventID := range b.processedEvents {
+		eventIDs = append(eventIDs, eventID)
+	}
+	b.mu.RUnlock()
+
+	for _, eventID := range eventIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(eventID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
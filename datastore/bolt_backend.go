@@ -0,0 +1,173 @@
+package datastore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/customerio/homework/serve"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	customersBucket = []byte("customers")
+	eventsBucket    = []byte("processed_events")
+)
+
+// boltBackend persists customers and processed-event markers to a BoltDB
+// file, so a multi-million-record ingest only has to happen once and is
+// reopened instantly on the next start.
+type boltBackend struct {
+	db *bolt.DB
+}
+
+// newBoltBackend opens (creating if necessary) the BoltDB file at path.
+func newBoltBackend(path string) (*boltBackend, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(customersBucket); err != nil {
+			return err
+		}
+
+		_, err := tx.CreateBucketIfNotExists(eventsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &boltBackend{db: db}, nil
+}
+
+// NewBoltBackend returns a Backend persisted to a BoltDB file at path,
+// creating it if it doesn't already exist.
+func NewBoltBackend(path string) (Backend, error) {
+	return newBoltBackend(path)
+}
+
+func (b *boltBackend) Close() error {
+	return b.db.Close()
+}
+
+func customerKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+func (b *boltBackend) GetCustomer(ctx context.Context, id int) (*serve.Customer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var customer serve.Customer
+	found := false
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(customersBucket).Get(customerKey(id))
+		if data == nil {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(data, &customer)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, errCustomerNotFound
+	}
+
+	return &customer, nil
+}
+
+func (b *boltBackend) PutCustomer(ctx context.Context, customer *serve.Customer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(customer)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(customersBucket).Put(customerKey(customer.ID), data)
+	})
+}
+
+func (b *boltBackend) DeleteCustomer(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(customersBucket)
+		if bucket.Get(customerKey(id)) == nil {
+			return errCustomerNotFound
+		}
+
+		return bucket.Delete(customerKey(id))
+	})
+}
+
+func (b *boltBackend) IterateCustomers(ctx context.Context, fn func(*serve.Customer) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(customersBucket).ForEach(func(_, data []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var customer serve.Customer
+			if err := json.Unmarshal(data, &customer); err != nil {
+				return err
+			}
+
+			return fn(&customer)
+		})
+	})
+}
+
+func (b *boltBackend) MarkEventProcessed(ctx context.Context, eventID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(eventsBucket).Put([]byte(eventID), []byte{1})
+	})
+}
+
+func (b *boltBackend) HasProcessedEvent(ctx context.Context, eventID string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	var processed bool
+	err := b.db.View(func(tx *bolt.Tx) error {
+		processed = tx.Bucket(eventsBucket).Get([]byte(eventID)) != nil
+		return nil
+	})
+
+	return processed, err
+}
+
+func (b *boltBackend) IterateProcessedEvents(ctx context.Context, fn func(eventID string) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(eventsBucket).ForEach(func(key, _ []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			return fn(string(key))
+		})
+	})
+}
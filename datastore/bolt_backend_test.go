@@ -0,0 +1,125 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/customerio/homework/serve"
+)
+
+// TestBoltBackendRoundTrip is a smoke test for the BoltDB-backed Backend:
+// Put/Get/Delete and IterateCustomers against a temp-dir-backed database
+// file, plus the errCustomerNotFound semantics Get and Delete must share
+// with memoryBackend.
+func TestBoltBackendRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "customers.bolt")
+	backend, err := newBoltBackend(path)
+	if err != nil {
+		t.Fatalf("newBoltBackend failed: %v", err)
+	}
+	defer backend.Close()
+
+	if _, err := backend.GetCustomer(ctx, 1); !errors.Is(err, errCustomerNotFound) {
+		t.Fatalf("got err %v, want errCustomerNotFound", err)
+	}
+
+	customer := &serve.Customer{
+		ID:         1,
+		Attributes: map[string]string{"plan": "pro"},
+		Events:     map[string]int{"purchased": 2},
+	}
+	if err := backend.PutCustomer(ctx, customer); err != nil {
+		t.Fatalf("PutCustomer failed: %v", err)
+	}
+
+	got, err := backend.GetCustomer(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetCustomer failed: %v", err)
+	}
+	if got.Attributes["plan"] != "pro" || got.Events["purchased"] != 2 {
+		t.Fatalf("got customer %+v, want plan=pro purchased=2", got)
+	}
+
+	if err := backend.PutCustomer(ctx, &serve.Customer{ID: 2, Attributes: map[string]string{"plan": "free"}}); err != nil {
+		t.Fatalf("PutCustomer failed: %v", err)
+	}
+
+	var ids []int
+	err = backend.IterateCustomers(ctx, func(c *serve.Customer) error {
+		ids = append(ids, c.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateCustomers failed: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("got %d customers, want 2", len(ids))
+	}
+
+	if err := backend.DeleteCustomer(ctx, 1); err != nil {
+		t.Fatalf("DeleteCustomer failed: %v", err)
+	}
+	if _, err := backend.GetCustomer(ctx, 1); !errors.Is(err, errCustomerNotFound) {
+		t.Fatalf("got err %v, want errCustomerNotFound after delete", err)
+	}
+	if err := backend.DeleteCustomer(ctx, 1); !errors.Is(err, errCustomerNotFound) {
+		t.Fatalf("got err %v, want errCustomerNotFound deleting an already-deleted customer", err)
+	}
+
+	if err := backend.MarkEventProcessed(ctx, "evt-1"); err != nil {
+		t.Fatalf("MarkEventProcessed failed: %v", err)
+	}
+	processed, err := backend.HasProcessedEvent(ctx, "evt-1")
+	if err != nil {
+		t.Fatalf("HasProcessedEvent failed: %v", err)
+	}
+	if !processed {
+		t.Fatal("evt-1 should be marked processed")
+	}
+
+	processed, err = backend.HasProcessedEvent(ctx, "evt-unseen")
+	if err != nil {
+		t.Fatalf("HasProcessedEvent failed: %v", err)
+	}
+	if processed {
+		t.Fatal("evt-unseen should not be marked processed")
+	}
+}
+
+// TestBoltBackendReopen confirms data written to a BoltDB file survives
+// closing and reopening it, the property the whole backend exists for: a
+// large ingest only has to happen once.
+func TestBoltBackendReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "customers.bolt")
+
+	backend, err := newBoltBackend(path)
+	if err != nil {
+		t.Fatalf("newBoltBackend failed: %v", err)
+	}
+
+	if err := backend.PutCustomer(ctx, &serve.Customer{ID: 42, Attributes: map[string]string{"plan": "pro"}}); err != nil {
+		t.Fatalf("PutCustomer failed: %v", err)
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := newBoltBackend(path)
+	if err != nil {
+		t.Fatalf("newBoltBackend (reopen) failed: %v", err)
+	}
+	defer reopened.Close()
+
+	customer, err := reopened.GetCustomer(ctx, 42)
+	if err != nil {
+		t.Fatalf("GetCustomer failed: %v", err)
+	}
+	if customer.Attributes["plan"] != "pro" {
+		t.Fatalf("got attributes %v, want plan=pro", customer.Attributes)
+	}
+}
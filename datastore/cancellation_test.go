@@ -0,0 +1,87 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/customerio/homework/serve"
+)
+
+// TestIngestCancelledContextSequential is a regression test for ctx
+// propagation through the single-threaded ingest path: a context cancelled
+// before Ingest is even called must stop the for record := range
+// inputChannel loop on its first iteration rather than draining the whole
+// channel.
+func TestIngestCancelledContextSequential(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ds := newAutobatchDatastore()
+
+	if err := ds.Ingest(ctx, syntheticStream(1_000, 50), 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got err %v, want context.Canceled", err)
+	}
+
+	total := 0
+	err := ds.backend.IterateCustomers(context.Background(), func(*serve.Customer) error {
+		total++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateCustomers failed: %v", err)
+	}
+	if total != 0 {
+		t.Fatalf("got %d customers ingested, want 0 (ctx was already cancelled)", total)
+	}
+}
+
+// TestIngestCancelledContextSharded is the same regression as above for the
+// sharded path, which fans records out across per-worker goroutines rather
+// than looping on the calling goroutine directly.
+func TestIngestCancelledContextSharded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ds := newAutobatchDatastore()
+
+	if err := ds.Ingest(ctx, syntheticStream(1_000, 50), 4); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got err %v, want context.Canceled", err)
+	}
+}
+
+// TestListCancelledContext confirms List stops scanning and returns the
+// context error instead of running the full IterateCustomers pass when ctx
+// is already cancelled - the property the cancellation check at the top of
+// List exists to provide.
+func TestListCancelledContext(t *testing.T) {
+	backend := newMemoryBackend()
+	seedCustomers(t, context.Background(), backend, 10)
+
+	ds := &Datastore{backend: newAutobatch(backend, defaultAutobatchThreshold)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ds.List(ctx, serve.ListOptions{PageSize: 5})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got err %v, want context.Canceled", err)
+	}
+}
+
+// TestGetCancelledContext confirms Get propagates a cancelled context down
+// to the backend instead of returning a customer looked up past the point
+// the caller stopped waiting.
+func TestGetCancelledContext(t *testing.T) {
+	backend := newMemoryBackend()
+	seedCustomers(t, context.Background(), backend, 1)
+
+	ds := &Datastore{backend: newAutobatch(backend, defaultAutobatchThreshold)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ds.Get(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got err %v, want context.Canceled", err)
+	}
+}
@@ -1,6 +1,7 @@
 package datastore
 
 import (
+	"context"
 	"errors"
 	"strconv"
 	"time"
@@ -10,26 +11,61 @@ import (
 	"github.com/labstack/gommon/log"
 )
 
+// Datastore is a serve.Datastore backed by a pluggable Backend. Writes pass
+// through an autobatch buffer so ingesting a large log file doesn't round
+// trip to the underlying storage once per record.
 type Datastore struct {
-	customers []*serve.Customer
+	backend *autobatch
+}
+
+// New replays inputChannel into backend (wrapped in an autobatch write
+// buffer) and returns the resulting Datastore. It's equivalent to creating
+// an empty Datastore over backend and calling Ingest on it - see Ingest for
+// details, including the meaning of workers.
+func New(ctx context.Context, inputChannel <-chan *stream.Record, backend Backend, workers int) (serve.Datastore, error) {
+	store := &Datastore{
+		backend: newAutobatch(backend, defaultAutobatchThreshold),
+	}
 
-	// link the customer id to the position in the 'customers' slice
-	customerLinks map[int]int
+	if err := store.Ingest(ctx, inputChannel, workers); err != nil {
+		return nil, err
+	}
 
-	// map to keep track of processed events
-	processedEventsMap map[string]bool
+	return store, nil
 }
 
-// New creates an in-memory instance of the datastore from an input channel of records
-func New(inputChannel <-chan *stream.Record) (serve.Datastore, error) {
-	store := Datastore{
-		customers:          make([]*serve.Customer, 0),
-		customerLinks:      make(map[int]int),
-		processedEventsMap: make(map[string]bool),
+// Ingest replays inputChannel into ds, merging with whatever ds already
+// holds (e.g. customers restored from a snapshot). Ingestion stops early,
+// returning ctx.Err(), if ctx is cancelled before the channel is drained.
+// The autobatch buffer is flushed once replay finishes, so the backend
+// holds a complete, durable copy before Ingest returns.
+//
+// workers controls ingest concurrency: 1 replays inputChannel serially on
+// the calling goroutine (deterministic, easy to reason about in tests); any
+// higher value fans records out across that many sharded workers (see
+// shard.go) to use more than one core.
+func (ds *Datastore) Ingest(ctx context.Context, inputChannel <-chan *stream.Record, workers int) error {
+	var err error
+	if workers <= 1 {
+		err = ingestSequential(ctx, ds, inputChannel)
+	} else {
+		err = ingestSharded(ctx, ds, inputChannel, workers)
+	}
+	if err != nil {
+		return err
 	}
 
+	return ds.backend.Sync(ctx)
+}
+
+// ingestSequential processes records one at a time on the calling goroutine.
+func ingestSequential(ctx context.Context, store *Datastore, inputChannel <-chan *stream.Record) error {
 	for record := range inputChannel {
-		exists, err := isUserRegistered(&store, record.UserID)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		exists, err := isUserRegistered(ctx, store, record.UserID)
 		if err != nil {
 			log.Errorf("Failed to check if user is registered: %s: %v", record.UserID, err)
 			continue
@@ -38,7 +74,8 @@ func New(inputChannel <-chan *stream.Record) (serve.Datastore, error) {
 		if !exists {
 			// if user is not registered, create new customer
 			_, err = registerCustomerFromRecord(
-				&store,
+				ctx,
+				store,
 				record,
 			)
 			if err != nil {
@@ -47,16 +84,23 @@ func New(inputChannel <-chan *stream.Record) (serve.Datastore, error) {
 		}
 
 		// process the event against the registered customer
-		if err = processRecord(&store, record); err != nil {
+		if err = processRecord(ctx, store, record); err != nil {
 			log.Errorf("Failed to process record: %v", err)
-			return nil, err
+			return err
 		}
 	}
 
-	return &store, nil
+	return nil
+}
+
+// Sync flushes any writes still buffered in the autobatch layer. main calls
+// this on graceful shutdown so nothing written after the initial replay is
+// lost.
+func (ds *Datastore) Sync(ctx context.Context) error {
+	return ds.backend.Sync(ctx)
 }
 
-func isUserRegistered(ds *Datastore, customerId string) (bool, error) {
+func isUserRegistered(ctx context.Context, ds *Datastore, customerId string) (bool, error) {
 	if ds == nil {
 		return false, errors.New("datastore is nil")
 	}
@@ -67,14 +111,18 @@ func isUserRegistered(ds *Datastore, customerId string) (bool, error) {
 		return false, err
 	}
 
-	if _, exists := ds.customerLinks[userId]; !exists {
-		return false, nil
+	_, err = ds.backend.GetCustomer(ctx, userId)
+	if err != nil {
+		if errors.Is(err, errCustomerNotFound) {
+			return false, nil
+		}
+		return false, err
 	}
 
 	return true, nil
 }
 
-func registerCustomerFromRecord(ds *Datastore, record *stream.Record) (*serve.Customer, error) {
+func registerCustomerFromRecord(ctx context.Context, ds *Datastore, record *stream.Record) (*serve.Customer, error) {
 	if ds == nil {
 		return nil, errors.New("datastore is nil")
 	}
@@ -92,13 +140,14 @@ func registerCustomerFromRecord(ds *Datastore, record *stream.Record) (*serve.Cu
 		LastUpdated: int(record.Timestamp),
 	}
 
-	ds.customers = append(ds.customers, newCustomer)
-	ds.customerLinks[userId] = len(ds.customers) - 1
+	if err := ds.backend.PutCustomer(ctx, newCustomer); err != nil {
+		return nil, err
+	}
 
 	return newCustomer, nil
 }
 
-func registerCustomer(ds *Datastore, id int, attributes map[string]string) (*serve.Customer, error) {
+func registerCustomer(ctx context.Context, ds *Datastore, id int, attributes map[string]string) (*serve.Customer, error) {
 	if ds == nil {
 		return nil, errors.New("datastore is nil")
 	}
@@ -114,13 +163,14 @@ func registerCustomer(ds *Datastore, id int, attributes map[string]string) (*ser
 		newCustomer.Attributes[attrName] = attrVal
 	}
 
-	ds.customers = append(ds.customers, newCustomer)
-	ds.customerLinks[id] = len(ds.customers) - 1
+	if err := ds.backend.PutCustomer(ctx, newCustomer); err != nil {
+		return nil, err
+	}
 
 	return newCustomer, nil
 }
 
-func processRecord(ds *Datastore, record *stream.Record) error {
+func processRecord(ctx context.Context, ds *Datastore, record *stream.Record) error {
 	if ds == nil {
 		return errors.New("datastore is nil")
 	}
@@ -131,12 +181,19 @@ func processRecord(ds *Datastore, record *stream.Record) error {
 		return err
 	}
 
-	customer := ds.customers[ds.customerLinks[customerId]]
+	customer, err := ds.backend.GetCustomer(ctx, customerId)
+	if err != nil {
+		return err
+	}
 
 	switch record.Type {
 	case "event":
 		// skip processing if already processed
-		if _, exists := ds.processedEventsMap[record.ID]; exists {
+		processed, err := ds.backend.HasProcessedEvent(ctx, record.ID)
+		if err != nil {
+			return err
+		}
+		if processed {
 			return nil
 		}
 
@@ -148,7 +205,9 @@ func processRecord(ds *Datastore, record *stream.Record) error {
 		}
 
 		// mark event as processed
-		ds.processedEventsMap[record.ID] = true
+		if err := ds.backend.MarkEventProcessed(ctx, record.ID); err != nil {
+			return err
+		}
 	case "attributes":
 		if int(record.Timestamp) >= customer.LastUpdated {
 			// set each attribute as-is
@@ -163,88 +222,71 @@ func processRecord(ds *Datastore, record *stream.Record) error {
 		customer.LastUpdated = int(record.Timestamp)
 	}
 
-	return nil
-}
-
-func fixLinks(ds *Datastore) error {
-	if ds == nil {
-		return errors.New("datastore is nil")
-	}
-
-	// // loop through customers and fix links
-	// for i, customer := range ds.customers {
-	// 	ds.customerLinks[customer.ID] = i
-	// }
-
-	// loop through customers and fix links (2-way to half time)
-	for i, j := 0, len(ds.customers)-1; i < j; i, j = i+1, j-1 {
-		ds.customerLinks[ds.customers[i].ID], ds.customerLinks[ds.customers[j].ID] = i, j
-	}
-
-	return nil
+	return ds.backend.PutCustomer(ctx, customer)
 }
 
 // Get retrieves a customer's data by ID
-func (ds *Datastore) Get(id int) (*serve.Customer, error) {
-	pos, exists := ds.customerLinks[id]
-	if !exists {
-		return nil, errors.New("customer not found")
-	}
-
-	customer := ds.customers[pos]
-	return customer, nil
-}
-
-// List retrieves all customers in the datastore
-func (ds *Datastore) List(page, count int) ([]*serve.Customer, error) {
-	return ds.customers, nil
+func (ds *Datastore) Get(ctx context.Context, id int) (*serve.Customer, error) {
+	return ds.backend.GetCustomer(ctx, id)
 }
 
 // Create adds a new customer to the datastore
-func (ds *Datastore) Create(id int, attributes map[string]string) (*serve.Customer, error) {
-	if _, exists := ds.customerLinks[id]; exists {
+func (ds *Datastore) Create(ctx context.Context, id int, attributes map[string]string) (*serve.Customer, error) {
+	if _, err := ds.backend.GetCustomer(ctx, id); err == nil {
 		return nil, errors.New("customer already exists")
+	} else if !errors.Is(err, errCustomerNotFound) {
+		return nil, err
 	}
 
-	return registerCustomer(ds, id, attributes)
+	return registerCustomer(ctx, ds, id, attributes)
 }
 
 // Update updates a customer's attribute data
-func (ds *Datastore) Update(id int, attributes map[string]string) (*serve.Customer, error) {
-	pos, exists := ds.customerLinks[id]
-	if !exists {
-		return nil, errors.New("customer does not exist")
+func (ds *Datastore) Update(ctx context.Context, id int, attributes map[string]string) (*serve.Customer, error) {
+	customer, err := ds.backend.GetCustomer(ctx, id)
+	if err != nil {
+		if errors.Is(err, errCustomerNotFound) {
+			return nil, errors.New("customer does not exist")
+		}
+		return nil, err
 	}
 
-	customer := ds.customers[pos]
 	for attrName, attrVal := range attributes {
 		customer.Attributes[attrName] = attrVal
 	}
 
 	customer.LastUpdated = int(time.Now().Unix())
 
+	if err := ds.backend.PutCustomer(ctx, customer); err != nil {
+		return nil, err
+	}
+
 	return customer, nil
 }
 
 // Delete removes a customer from the datastore
-func (ds *Datastore) Delete(id int) error {
-	pos, exists := ds.customerLinks[id]
-	if !exists {
-		return errors.New("customer not found")
+func (ds *Datastore) Delete(ctx context.Context, id int) error {
+	if err := ds.backend.DeleteCustomer(ctx, id); err != nil {
+		if errors.Is(err, errCustomerNotFound) {
+			return errors.New("customer not found")
+		}
+		return err
 	}
 
-	// delete link
-	delete(ds.customerLinks, id)
-
-	// delete customer
-	ds.customers[pos] = ds.customers[len(ds.customers)-1]
-	ds.customers = ds.customers[:len(ds.customers)-1]
-
-	// recalculate links
-	return fixLinks(ds)
+	return nil
 }
 
 // TotalCustomers returns the total number of customers in the datastore
-func (ds *Datastore) TotalCustomers() (int, error) {
-	return len(ds.customers), nil
+func (ds *Datastore) TotalCustomers(ctx context.Context) (int, error) {
+	total := 0
+
+	err := ds.backend.IterateCustomers(ctx, func(*serve.Customer) error {
+		total++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
 }
@@ -0,0 +1,183 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/customerio/homework/serve"
+	"github.com/customerio/homework/stream"
+)
+
+// syntheticStream returns a channel emitting n records across numCustomers
+// distinct customers, split between "attributes" and "event" records, the
+// same shape Process would produce from the on-disk log.
+func syntheticStream(n, numCustomers int) <-chan *stream.Record {
+	out := make(chan *stream.Record, 256)
+
+	go func() {
+		defer close(out)
+
+		for i := 0; i < n; i++ {
+			userID := strconv.Itoa(i % numCustomers)
+
+			if i%5 == 0 {
+				out <- &stream.Record{
+					ID:        fmt.Sprintf("attr-%d", i),
+					UserID:    userID,
+					Type:      "attributes",
+					Timestamp: int64(i),
+					Data:      map[string]string{"plan": "pro"},
+				}
+				continue
+			}
+
+			out <- &stream.Record{
+				ID:        fmt.Sprintf("evt-%d", i),
+				UserID:    userID,
+				Type:      "event",
+				Name:      "purchased",
+				Timestamp: int64(i),
+			}
+		}
+	}()
+
+	return out
+}
+
+// benchmarkIngest ingests a synthetic million-record stream with workers
+// concurrent shards and reports throughput.
+func benchmarkIngest(b *testing.B, workers int) {
+	const recordCount = 1_000_000
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		ds := newAutobatchDatastore()
+		ch := syntheticStream(recordCount, 10_000)
+		b.StartTimer()
+
+		if err := ds.Ingest(context.Background(), ch, workers); err != nil {
+			b.Fatalf("Ingest failed: %v", err)
+		}
+	}
+
+	b.StopTimer()
+	elapsed := b.Elapsed()
+	if elapsed > 0 {
+		b.ReportMetric(float64(b.N*recordCount)/elapsed.Seconds(), "records/sec")
+	}
+}
+
+func BenchmarkIngestWorkers1(b *testing.B) {
+	benchmarkIngest(b, 1)
+}
+
+func BenchmarkIngestWorkers4(b *testing.B) {
+	benchmarkIngest(b, 4)
+}
+
+func BenchmarkIngestWorkersNumCPU(b *testing.B) {
+	benchmarkIngest(b, runtime.NumCPU())
+}
+
+// newAutobatchDatastore builds an empty Datastore over a fresh in-memory
+// backend, bypassing New so tests can call Ingest directly.
+func newAutobatchDatastore() *Datastore {
+	return &Datastore{backend: newAutobatch(newMemoryBackend(), defaultAutobatchThreshold)}
+}
+
+// TestIngestDeterministic checks that --workers=1 produces the same final
+// counts on every run over the same synthetic stream.
+func TestIngestDeterministic(t *testing.T) {
+	ctx := context.Background()
+
+	ds := newAutobatchDatastore()
+
+	if err := ds.Ingest(ctx, syntheticStream(5_000, 50), 1); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+
+	want := map[int]int{}
+	err := ds.backend.IterateCustomers(ctx, func(c *serve.Customer) error {
+		want[c.ID] = c.Events["purchased"]
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateCustomers failed: %v", err)
+	}
+
+	for run := 0; run < 3; run++ {
+		ds := newAutobatchDatastore()
+
+		if err := ds.Ingest(ctx, syntheticStream(5_000, 50), 1); err != nil {
+			t.Fatalf("Ingest failed: %v", err)
+		}
+
+		got := map[int]int{}
+		err := ds.backend.IterateCustomers(ctx, func(c *serve.Customer) error {
+			got[c.ID] = c.Events["purchased"]
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("IterateCustomers failed: %v", err)
+		}
+
+		for id, count := range want {
+			if got[id] != count {
+				t.Fatalf("run %d: customer %d: got %d purchased events, want %d", run, id, got[id], count)
+			}
+		}
+	}
+}
+
+// TestIngestShardedMergesExistingBackendState is a regression test for the
+// sharded path overwriting (rather than merging into) customers and
+// processed events already present in the backend - the state a resumed
+// snapshot restores before incremental ingest replays the records written
+// since the snapshot was taken.
+func TestIngestShardedMergesExistingBackendState(t *testing.T) {
+	ctx := context.Background()
+
+	backend := newMemoryBackend()
+	if err := backend.PutCustomer(ctx, &serve.Customer{
+		ID:          7,
+		Attributes:  map[string]string{"plan": "pro"},
+		Events:      map[string]int{"purchased": 3},
+		LastUpdated: 100,
+	}); err != nil {
+		t.Fatalf("PutCustomer failed: %v", err)
+	}
+	if err := backend.MarkEventProcessed(ctx, "evt-already-seen"); err != nil {
+		t.Fatalf("MarkEventProcessed failed: %v", err)
+	}
+
+	ds := &Datastore{backend: newAutobatch(backend, defaultAutobatchThreshold)}
+
+	ch := make(chan *stream.Record, 2)
+	ch <- &stream.Record{ID: "evt-already-seen", UserID: "7", Type: "event", Name: "purchased", Timestamp: 200}
+	ch <- &stream.Record{ID: "evt-new", UserID: "7", Type: "event", Name: "purchased", Timestamp: 201}
+	close(ch)
+
+	if err := ds.Ingest(ctx, ch, 4); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+
+	customer, err := ds.backend.GetCustomer(ctx, 7)
+	if err != nil {
+		t.Fatalf("GetCustomer failed: %v", err)
+	}
+
+	if customer.Attributes["plan"] != "pro" {
+		t.Fatalf("attribute set before the snapshot was lost: got %q, want %q", customer.Attributes["plan"], "pro")
+	}
+
+	// the already-processed event must not be recounted, so only evt-new
+	// should have bumped the total past the snapshot's count of 3
+	if customer.Events["purchased"] != 4 {
+		t.Fatalf("got %d purchased events, want 4 (3 restored + 1 new, duplicate skipped)", customer.Events["purchased"])
+	}
+}
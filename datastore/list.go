@@ -0,0 +1,97 @@
+package datastore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/customerio/homework/serve"
+)
+
+const defaultPageSize = 50
+
+// errStopIteration is an internal sentinel used to break out of
+// Backend.IterateCustomers once a page is full, so List doesn't have to
+// materialize the whole backend to serve one page.
+var errStopIteration = errors.New("stop iteration")
+
+// encodePageToken turns the last customer ID returned in a page into the
+// opaque cursor handed back to clients.
+func encodePageToken(id int) string {
+	return base64.URLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// decodePageToken reverses encodePageToken. An empty token decodes to 0;
+// List treats an empty token as "start from the beginning".
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	data, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid page token: %w", err)
+	}
+
+	id, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("invalid page token: %w", err)
+	}
+
+	return id, nil
+}
+
+func matchesAttributes(customer *serve.Customer, filters map[string]string) bool {
+	for name, value := range filters {
+		if customer.Attributes[name] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// List retrieves a page of customers in ascending ID order, optionally
+// filtered by opts.Attributes. Pagination cursors on customer ID (opaque
+// base64 in the page token) rather than offset, so Create and Delete -
+// which mutate the backend concurrently - can't shift a page out from under
+// a client mid-scan the way offset-based paging would.
+func (ds *Datastore) List(ctx context.Context, opts serve.ListOptions) (*serve.CustomerIterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	afterID, err := decodePageToken(opts.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var page []*serve.Customer
+	var nextPageToken string
+
+	err = ds.backend.IterateCustomers(ctx, func(customer *serve.Customer) error {
+		if (opts.PageToken != "" && customer.ID <= afterID) || !matchesAttributes(customer, opts.Attributes) {
+			return nil
+		}
+
+		if len(page) == pageSize {
+			nextPageToken = encodePageToken(page[len(page)-1].ID)
+			return errStopIteration
+		}
+
+		page = append(page, customer)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopIteration) {
+		return nil, err
+	}
+
+	return serve.NewCustomerIterator(page, nextPageToken), nil
+}
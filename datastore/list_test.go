@@ -0,0 +1,133 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/customerio/homework/serve"
+)
+
+// seedCustomers populates backend with customers 1..n, alternating the
+// "plan" attribute between "pro" and "free" so filter tests have both to
+// match against.
+func seedCustomers(t *testing.T, ctx context.Context, backend Backend, n int) {
+	t.Helper()
+
+	for id := 1; id <= n; id++ {
+		plan := "free"
+		if id%2 == 0 {
+			plan = "pro"
+		}
+
+		err := backend.PutCustomer(ctx, &serve.Customer{
+			ID:         id,
+			Attributes: map[string]string{"plan": plan},
+			Events:     map[string]int{},
+		})
+		if err != nil {
+			t.Fatalf("PutCustomer(%d) failed: %v", id, err)
+		}
+	}
+}
+
+func drainPage(t *testing.T, it *serve.CustomerIterator) []int {
+	t.Helper()
+
+	var ids []int
+	for {
+		customer, err := it.Next()
+		if err == serve.ErrIteratorDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		ids = append(ids, customer.ID)
+	}
+
+	return ids
+}
+
+// TestListMultiPageAttributeFilter pages through customers matching an
+// attribute filter and confirms every page only contains matches and that
+// paging through to the end covers every matching customer exactly once.
+func TestListMultiPageAttributeFilter(t *testing.T) {
+	ctx := context.Background()
+	backend := newMemoryBackend()
+	seedCustomers(t, ctx, backend, 10)
+
+	ds := &Datastore{backend: newAutobatch(backend, defaultAutobatchThreshold)}
+
+	opts := serve.ListOptions{PageSize: 2, Attributes: map[string]string{"plan": "pro"}}
+
+	var got []int
+	for {
+		it, err := ds.List(ctx, opts)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+
+		got = append(got, drainPage(t, it)...)
+
+		if it.NextPageToken() == "" {
+			break
+		}
+		opts.PageToken = it.NextPageToken()
+	}
+
+	want := []int{2, 4, 6, 8, 10}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i, id := range want {
+		if got[i] != id {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+// TestListStableAcrossConcurrentDelete is a regression test for the
+// cursor-based design: since the page token encodes the last-seen customer
+// ID rather than a positional offset, deleting a customer that a client has
+// already been handed must not shift or duplicate entries in the next page
+// the way offset-based paging would.
+func TestListStableAcrossConcurrentDelete(t *testing.T) {
+	ctx := context.Background()
+	backend := newMemoryBackend()
+	seedCustomers(t, ctx, backend, 5)
+
+	ds := &Datastore{backend: newAutobatch(backend, defaultAutobatchThreshold)}
+
+	firstPage, err := ds.List(ctx, serve.ListOptions{PageSize: 2})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	gotFirst := drainPage(t, firstPage)
+	if len(gotFirst) != 2 || gotFirst[0] != 1 || gotFirst[1] != 2 {
+		t.Fatalf("got first page %v, want [1 2]", gotFirst)
+	}
+
+	token := firstPage.NextPageToken()
+	if token == "" {
+		t.Fatal("expected a next page token after the first page")
+	}
+
+	// A client has already seen customer 2 by this point; deleting it here
+	// simulates a mutation racing with pagination after the cursor was
+	// handed out but before the next page is fetched.
+	if err := backend.DeleteCustomer(ctx, 2); err != nil {
+		t.Fatalf("DeleteCustomer failed: %v", err)
+	}
+
+	secondPage, err := ds.List(ctx, serve.ListOptions{PageSize: 2, PageToken: token})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	gotSecond := drainPage(t, secondPage)
+	want := []int{3, 4}
+	if len(gotSecond) != len(want) || gotSecond[0] != want[0] || gotSecond[1] != want[1] {
+		t.Fatalf("got second page %v, want %v (no skip or duplicate from deleting an already-returned customer)", gotSecond, want)
+	}
+}
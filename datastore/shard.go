@@ -0,0 +1,228 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"github.com/customerio/homework/serve"
+	"github.com/customerio/homework/stream"
+	"github.com/labstack/gommon/log"
+)
+
+// shard is the per-worker staging area used by ingestSharded. Because
+// records are dispatched to workers by hashing UserID, a given customer is
+// always owned by exactly one shard during ingestion, so its maps need no
+// locking on the hot path.
+type shard struct {
+	customers       map[int]*serve.Customer
+	processedEvents map[string]bool
+}
+
+func newShard() *shard {
+	return &shard{
+		customers:       make(map[int]*serve.Customer),
+		processedEvents: make(map[string]bool),
+	}
+}
+
+// shardFor deterministically maps a customer ID to one of workers shards.
+func shardFor(userID string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return int(h.Sum32() % uint32(workers))
+}
+
+// ingestSharded fans inputChannel out across workers goroutines, each owning
+// a disjoint slice of customers, then merges the resulting shards into
+// store's backend.
+func ingestSharded(ctx context.Context, store *Datastore, inputChannel <-chan *stream.Record, workers int) error {
+	shardChannels := make([]chan *stream.Record, workers)
+	for i := range shardChannels {
+		shardChannels[i] = make(chan *stream.Record, 256)
+	}
+
+	go dispatch(ctx, inputChannel, shardChannels)
+
+	shards := make([]*shard, workers)
+	errs := make([]error, workers)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			shards[i], errs[i] = ingestShard(ctx, store, shardChannels[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	// dispatch stops forwarding records as soon as ctx is cancelled, but it
+	// does so by closing the shard channels rather than returning an error,
+	// so a worker whose channel was already empty and closed reports no
+	// error of its own. Check here too, or a cancellation that raced ahead
+	// of every worker would merge a silently incomplete set of shards and
+	// report success.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return mergeShards(ctx, store, shards)
+}
+
+// dispatch hashes each record's UserID to a shard and forwards it there,
+// closing every shard channel once inputChannel is drained or ctx is
+// cancelled.
+func dispatch(ctx context.Context, inputChannel <-chan *stream.Record, shardChannels []chan *stream.Record) {
+	defer func() {
+		for _, ch := range shardChannels {
+			close(ch)
+		}
+	}()
+
+	for record := range inputChannel {
+		if ctx.Err() != nil {
+			return
+		}
+
+		idx := shardFor(record.UserID, len(shardChannels))
+
+		select {
+		case shardChannels[idx] <- record:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ingestShard replays one worker's slice of records into a fresh, unlocked
+// shard using the same rules as the sequential path. store is consulted
+// read-only, to seed a shard's view of a customer (or an event's processed
+// state) from whatever the backend already holds - e.g. customers restored
+// from a snapshot - before mergeShards writes the shard back out.
+func ingestShard(ctx context.Context, store *Datastore, records <-chan *stream.Record) (*shard, error) {
+	s := newShard()
+
+	for record := range records {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if err := applyRecordToShard(ctx, store, s, record); err != nil {
+			log.Errorf("Failed to process record: %v", err)
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// applyRecordToShard registers the customer if needed and applies record to
+// it, mirroring processRecord's rules but against shard's unlocked maps. A
+// customer or processed-event not yet seen by this shard is read through to
+// store's backend first, rather than assumed to be new, so replaying records
+// over a pre-populated backend (e.g. after a snapshot restore) merges into
+// existing state instead of overwriting it.
+func applyRecordToShard(ctx context.Context, store *Datastore, s *shard, record *stream.Record) error {
+	userId, err := strconv.Atoi(record.UserID)
+	if err != nil {
+		log.Errorf("Invalid user ID: %s: %v", record.UserID, err)
+		return err
+	}
+
+	customer, exists := s.customers[userId]
+	if !exists {
+		customer, err = store.backend.GetCustomer(ctx, userId)
+		switch {
+		case err == nil:
+			// seed from the backend's existing copy so we merge into it
+			// instead of clobbering it with a blank customer below
+		case errors.Is(err, errCustomerNotFound):
+			customer = &serve.Customer{
+				ID:          userId,
+				Attributes:  make(map[string]string),
+				Events:      make(map[string]int),
+				LastUpdated: int(record.Timestamp),
+			}
+		default:
+			return err
+		}
+		s.customers[userId] = customer
+	}
+
+	switch record.Type {
+	case "event":
+		// skip processing if already processed, either earlier in this
+		// shard's replay or previously against the backend
+		processed, err := shardHasProcessedEvent(ctx, store, s, record.ID)
+		if err != nil {
+			return err
+		}
+		if processed {
+			return nil
+		}
+
+		// if event does not exist, create and set count to 1. otherwise just increase count
+		if _, exists := customer.Events[record.Name]; !exists {
+			customer.Events[record.Name] = 1
+		} else {
+			customer.Events[record.Name]++
+		}
+
+		// mark event as processed
+		s.processedEvents[record.ID] = true
+	case "attributes":
+		if int(record.Timestamp) >= customer.LastUpdated {
+			// set each attribute as-is
+			for attrName, attrVal := range record.Data {
+				customer.Attributes[attrName] = attrVal
+			}
+		}
+	}
+
+	// check and update customer last updated time
+	if customer.LastUpdated < int(record.Timestamp) {
+		customer.LastUpdated = int(record.Timestamp)
+	}
+
+	return nil
+}
+
+// shardHasProcessedEvent reports whether eventID has already been counted,
+// checking s's own (not yet merged) processed set before falling back to
+// store's backend.
+func shardHasProcessedEvent(ctx context.Context, store *Datastore, s *shard, eventID string) (bool, error) {
+	if s.processedEvents[eventID] {
+		return true, nil
+	}
+
+	return store.backend.HasProcessedEvent(ctx, eventID)
+}
+
+// mergeShards writes every shard's customers and processed events through to
+// store's backend, which buffers and flushes them.
+func mergeShards(ctx context.Context, store *Datastore, shards []*shard) error {
+	for _, s := range shards {
+		for _, customer := range s.customers {
+			if err := store.backend.PutCustomer(ctx, customer); err != nil {
+				return err
+			}
+		}
+
+		for eventID := range s.processedEvents {
+			if err := store.backend.MarkEventProcessed(ctx, eventID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
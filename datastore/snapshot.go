@@ -0,0 +1,201 @@
+package datastore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/customerio/homework/serve"
+)
+
+// snapshotMagic identifies a snapshot file; snapshotVersion lets the format
+// change later without misreading an older file.
+const (
+	snapshotMagic   = "CIOS"
+	snapshotVersion = 1
+)
+
+// maxSnapshotBodySize bounds the body length read from a snapshot's header.
+// The header isn't covered by the CRC32 (which only protects body), so a
+// truncated or corrupted file can claim an arbitrary bodyLen; without a cap
+// that feeds straight into make([]byte, bodyLen) and can panic the process
+// instead of falling back to a rebuild.
+const maxSnapshotBodySize = 1 << 30 // 1GiB
+
+// ErrCorruptSnapshot is returned when a snapshot file is truncated or its
+// checksum doesn't match its payload. Callers should fall back to a full
+// rebuild rather than trust the partial data.
+var ErrCorruptSnapshot = errors.New("datastore: corrupt snapshot")
+
+// snapshotPayload is the JSON body of a snapshot file: the full customer
+// table, the processed-event dedupe set, and the byte offset in the source
+// log up to which these records were replayed.
+type snapshotPayload struct {
+	Offset          int64             `json:"offset"`
+	Customers       []*serve.Customer `json:"customers"`
+	ProcessedEvents []string          `json:"processed_events"`
+}
+
+// Snapshot writes the current contents of ds to path, recording offset (the
+// byte position in the source log already replayed) so the next start can
+// resume from there instead of rescanning the whole file. The write goes to
+// a temp file and is renamed into place so a crash mid-write can't corrupt
+// an existing snapshot.
+func (ds *Datastore) Snapshot(ctx context.Context, path string, offset int64) error {
+	payload := snapshotPayload{Offset: offset}
+
+	err := ds.backend.IterateCustomers(ctx, func(customer *serve.Customer) error {
+		payload.Customers = append(payload.Customers, customer)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ds.backend.IterateProcessedEvents(ctx, func(eventID string) error {
+		payload.ProcessedEvents = append(payload.ProcessedEvents, eventID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := writeSnapshotHeader(file, body); err != nil {
+		file.Close()
+		return err
+	}
+
+	if _, err := file.Write(body); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// writeSnapshotHeader writes the fixed-size header: magic, version, a CRC32
+// checksum of body, and the length of body.
+func writeSnapshotHeader(w io.Writer, body []byte) error {
+	if _, err := w.Write([]byte(snapshotMagic)); err != nil {
+		return err
+	}
+
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint32(header[0:4], snapshotVersion)
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(body))
+	binary.BigEndian.PutUint64(header[8:16], uint64(len(body)))
+
+	_, err := w.Write(header)
+	return err
+}
+
+// LoadSnapshot reads the snapshot at path, verifies its checksum, and
+// restores its contents into backend (wrapped in an autobatch buffer like
+// New does). It returns the restored Datastore and the byte offset in the
+// source log the caller should seek to before streaming any remaining
+// records. A missing file is reported as a plain *PathError; a present but
+// truncated or checksum-mismatched file is reported as ErrCorruptSnapshot,
+// distinguishing "nothing to resume from" from "don't trust this".
+func LoadSnapshot(ctx context.Context, path string, backend Backend) (serve.Datastore, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	payload, err := readSnapshot(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	store := &Datastore{backend: newAutobatch(backend, defaultAutobatchThreshold)}
+
+	for _, customer := range payload.Customers {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		if err := store.backend.PutCustomer(ctx, customer); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	for _, eventID := range payload.ProcessedEvents {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		if err := store.backend.MarkEventProcessed(ctx, eventID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if err := store.backend.Sync(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return store, payload.Offset, nil
+}
+
+func readSnapshot(r io.Reader) (*snapshotPayload, error) {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+	if string(magic) != snapshotMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrCorruptSnapshot)
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+
+	version := binary.BigEndian.Uint32(header[0:4])
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrCorruptSnapshot, version)
+	}
+
+	wantChecksum := binary.BigEndian.Uint32(header[4:8])
+	bodyLen := binary.BigEndian.Uint64(header[8:16])
+
+	if bodyLen > maxSnapshotBodySize {
+		return nil, fmt.Errorf("%w: body length %d exceeds maximum %d", ErrCorruptSnapshot, bodyLen, maxSnapshotBodySize)
+	}
+
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+
+	if crc32.ChecksumIEEE(body) != wantChecksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptSnapshot)
+	}
+
+	var payload snapshotPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+
+	return &payload, nil
+}
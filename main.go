@@ -2,12 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/customerio/homework/datastore"
 	"github.com/customerio/homework/serve"
@@ -28,12 +35,45 @@ func main() {
 	var ds serve.Datastore
 
 	dataSource := flag.String("data-source", "", "file get records data from")
+	backendName := flag.String("backend", "memory", "storage backend to use: memory or bolt")
+	boltPath := flag.String("bolt-path", "./data/customers.db", "BoltDB file path, used when --backend=bolt")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of sharded ingest workers; use 1 for deterministic single-threaded ingestion")
+	readDeadline := flag.Duration("read-deadline", 5*time.Second, "max duration to read an HTTP request")
+	writeDeadline := flag.Duration("write-deadline", 10*time.Second, "max duration to write an HTTP response")
+	requestTimeout := flag.Duration("request-timeout", 5*time.Second, "max duration a handler may spend in the datastore before returning 503")
+	listTimeout := flag.Duration("list-timeout", 15*time.Second, "max duration GET /customers may spend scanning the datastore before returning 503")
+	rebuildSnapshot := flag.Bool("rebuild-snapshot", false, "ignore any existing snapshot and rescan the data source from the start")
 
 	flag.Parse()
 	if *dataSource == "" {
 		*dataSource = "./data/messages.1.data"
 	}
 
+	backend, closeBackend, err := newBackend(*backendName, *boltPath)
+	if err != nil {
+		log.Fatalf("Failed to open %s backend: %v", *backendName, err)
+	}
+	defer closeBackend()
+
+	snapshotPath := snapshotPathFor(*dataSource)
+
+	var resumeOffset int64
+	if !*rebuildSnapshot {
+		restored, offset, err := datastore.LoadSnapshot(ctx, snapshotPath, backend)
+		switch {
+		case err == nil:
+			ds = restored
+			resumeOffset = offset
+			fmt.Println("Restored datastore from snapshot, resuming at byte offset", offset)
+		case errors.Is(err, os.ErrNotExist):
+			// no snapshot yet, fall through to a full rescan
+		case errors.Is(err, datastore.ErrCorruptSnapshot):
+			log.Println("Snapshot is corrupt, rebuilding from scratch:", err)
+		default:
+			log.Println("Failed to load snapshot, rebuilding from scratch:", err)
+		}
+	}
+
 	fmt.Println("Using data source file:", *dataSource)
 	file, err := os.OpenFile(*dataSource, os.O_RDONLY, 0600)
 	if err != nil {
@@ -42,8 +82,24 @@ func main() {
 
 	defer file.Close()
 
+	if resumeOffset > 0 {
+		if _, err := file.Seek(resumeOffset, io.SeekStart); err != nil {
+			log.Fatalf("Failed to seek data file to snapshot offset %d: %v", resumeOffset, err)
+		}
+	}
+
 	if ch, err := stream.Process(ctx, file); err == nil {
-		ds, err = datastore.New(ch)
+		ch, consumed := trackOffset(ch)
+
+		if ds == nil {
+			ds, err = datastore.New(ctx, ch, backend, *workers)
+		} else if ingester, ok := ds.(interface {
+			Ingest(ctx context.Context, inputChannel <-chan *stream.Record, workers int) error
+		}); ok {
+			err = ingester.Ingest(ctx, ch, *workers)
+		} else {
+			err = fmt.Errorf("restored datastore does not support incremental ingest")
+		}
 		if err != nil {
 			log.Fatalf("Failed to load data store: %v", err)
 		}
@@ -51,6 +107,15 @@ func main() {
 		if err := ctx.Err(); err != nil {
 			log.Fatal(err)
 		}
+
+		if snapshotter, ok := ds.(interface {
+			Snapshot(ctx context.Context, path string, offset int64) error
+		}); ok {
+			finalOffset := resumeOffset + atomic.LoadInt64(consumed)
+			if err := snapshotter.Snapshot(ctx, snapshotPath, finalOffset); err != nil {
+				log.Println("Failed to write snapshot:", err)
+			}
+		}
 	} else {
 		log.Println("stream processing failed, maybe you need to implement it?", err)
 	}
@@ -59,7 +124,77 @@ func main() {
 		log.Fatal("you need to implement the serve.Datastore interface to run the server")
 	}
 
-	if err := serve.ListenAndServe(":1323", ds); err != nil {
+	go func() {
+		<-ctx.Done()
+		if syncer, ok := ds.(interface{ Sync(context.Context) error }); ok {
+			if err := syncer.Sync(context.Background()); err != nil {
+				log.Println("Failed to flush datastore on shutdown:", err)
+			}
+		}
+	}()
+
+	cfg := serve.Config{
+		ReadDeadline:   *readDeadline,
+		WriteDeadline:  *writeDeadline,
+		DefaultTimeout: *requestTimeout,
+		ListTimeout:    *listTimeout,
+	}
+
+	if err := serve.ListenAndServe(":1323", ds, cfg); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// snapshotPathFor derives a data source's companion snapshot path, e.g.
+// "./data/messages.1.data" -> "./data/messages.1.snapshot".
+func snapshotPathFor(dataSource string) string {
+	ext := filepath.Ext(dataSource)
+	return strings.TrimSuffix(dataSource, ext) + ".snapshot"
+}
+
+// trackOffset passes records through unchanged while recording the Offset
+// of the last one seen, so the caller can snapshot how far into the source
+// log it got.
+func trackOffset(in <-chan *stream.Record) (<-chan *stream.Record, *int64) {
+	out := make(chan *stream.Record)
+	var lastOffset int64
+
+	go func() {
+		defer close(out)
+
+		for record := range in {
+			atomic.StoreInt64(&lastOffset, record.Offset)
+			out <- record
+		}
+	}()
+
+	return out, &lastOffset
+}
+
+// newBackend constructs the storage backend named by --backend. It returns a
+// cleanup func that callers should always defer, even for backends (like
+// memory) that have nothing to close.
+func newBackend(name, boltPath string) (datastore.Backend, func(), error) {
+	switch name {
+	case "memory":
+		return datastore.NewMemoryBackend(), func() {}, nil
+	case "bolt":
+		backend, err := datastore.NewBoltBackend(boltPath)
+		if err != nil {
+			return nil, func() {}, err
+		}
+
+		closer, ok := backend.(interface{ Close() error })
+		if !ok {
+			return backend, func() {}, nil
+		}
+
+		return backend, func() {
+			if err := closer.Close(); err != nil {
+				log.Println("Failed to close bolt backend:", err)
+			}
+		}, nil
+	default:
+		return nil, func() {}, fmt.Errorf("unknown backend %q, want memory or bolt", name)
+	}
+}
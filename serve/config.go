@@ -0,0 +1,58 @@
+package serve
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Config controls deadlines for the HTTP API. A zero Config disables every
+// deadline, preserving the original behavior of letting requests run to
+// completion.
+type Config struct {
+	// ReadDeadline and WriteDeadline bound how long reading a request or
+	// writing a response may take at the connection level.
+	ReadDeadline  time.Duration
+	WriteDeadline time.Duration
+
+	// DefaultTimeout bounds how long a handler's call into the Datastore may
+	// take before the request is aborted with a 503.
+	DefaultTimeout time.Duration
+
+	// ListTimeout overrides DefaultTimeout for GET /customers, which can be
+	// an expensive scan once the store holds a lot of customers.
+	ListTimeout time.Duration
+}
+
+// timeoutFor returns the effective timeout for a route, falling back to
+// DefaultTimeout when override is unset.
+func (cfg Config) timeoutFor(override time.Duration) time.Duration {
+	if override > 0 {
+		return override
+	}
+
+	return cfg.DefaultTimeout
+}
+
+// withDeadline derives a context bounded by timeout from parent. A
+// non-positive timeout disables the deadline and returns parent unchanged.
+func withDeadline(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return parent, func() {}
+	}
+
+	return context.WithTimeout(parent, timeout)
+}
+
+// deadlineExceeded responds with HTTP 503 and a machine-readable body once a
+// handler's derived context expires. Returning promptly here - rather than
+// letting the handler keep waiting - relies on the Datastore honoring ctx
+// cancellation so no goroutine is left running the original request.
+func deadlineExceeded(c echo.Context) error {
+	return c.JSON(http.StatusServiceUnavailable, echo.Map{
+		"error": "request deadline exceeded",
+		"code":  "deadline_exceeded",
+	})
+}
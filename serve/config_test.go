@@ -0,0 +1,54 @@
+package serve
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConfigTimeoutFor(t *testing.T) {
+	cfg := Config{DefaultTimeout: 5 * time.Second, ListTimeout: 2 * time.Second}
+
+	if got := cfg.timeoutFor(cfg.ListTimeout); got != 2*time.Second {
+		t.Fatalf("got %v, want override of 2s", got)
+	}
+
+	if got := cfg.timeoutFor(0); got != 5*time.Second {
+		t.Fatalf("got %v, want fallback to DefaultTimeout of 5s", got)
+	}
+}
+
+func TestWithDeadlineDisabledWhenNonPositive(t *testing.T) {
+	parent := context.Background()
+
+	ctx, cancel := withDeadline(parent, 0)
+	defer cancel()
+
+	if ctx != parent {
+		t.Fatal("a non-positive timeout should return parent unchanged")
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("a non-positive timeout should not set a deadline")
+	}
+}
+
+func TestWithDeadlineBoundsParent(t *testing.T) {
+	parent := context.Background()
+
+	ctx, cancel := withDeadline(parent, 10*time.Millisecond)
+	defer cancel()
+
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("expected a deadline to be set")
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context did not expire within its timeout")
+	}
+
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatalf("got err %v, want context.DeadlineExceeded", ctx.Err())
+	}
+}
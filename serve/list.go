@@ -0,0 +1,48 @@
+package serve
+
+import "errors"
+
+// ErrIteratorDone is returned by CustomerIterator.Next once every customer
+// in the current page has been consumed.
+var ErrIteratorDone = errors.New("no more customers")
+
+// ListOptions configures a call to Datastore.List. PageToken, when set, must
+// be a value previously returned by CustomerIterator.NextPageToken.
+type ListOptions struct {
+	PageSize   int
+	PageToken  string
+	Attributes map[string]string
+}
+
+// CustomerIterator iterates over a single page of Datastore.List results.
+type CustomerIterator struct {
+	customers     []*Customer
+	pos           int
+	nextPageToken string
+}
+
+// NewCustomerIterator returns an iterator over customers for the current
+// page. nextPageToken is the token callers should pass back in ListOptions
+// to fetch the following page, or "" if this is the last page.
+func NewCustomerIterator(customers []*Customer, nextPageToken string) *CustomerIterator {
+	return &CustomerIterator{customers: customers, nextPageToken: nextPageToken}
+}
+
+// Next returns the next customer in the page, or ErrIteratorDone once
+// exhausted.
+func (it *CustomerIterator) Next() (*Customer, error) {
+	if it.pos >= len(it.customers) {
+		return nil, ErrIteratorDone
+	}
+
+	customer := it.customers[it.pos]
+	it.pos++
+
+	return customer, nil
+}
+
+// NextPageToken returns the opaque token for the page following this one, or
+// "" if there isn't one.
+func (it *CustomerIterator) NextPageToken() string {
+	return it.nextPageToken
+}
@@ -0,0 +1,206 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Customer represents a single customer record tracked by the datastore.
+type Customer struct {
+	ID          int               `json:"id"`
+	Attributes  map[string]string `json:"attributes"`
+	Events      map[string]int    `json:"events"`
+	LastUpdated int               `json:"last_updated"`
+}
+
+// Datastore is the storage interface the HTTP layer relies on to serve
+// customer data. Every method takes a context so a slow scan or list can be
+// cancelled when the requesting client disconnects or the process is
+// shutting down.
+type Datastore interface {
+	Get(ctx context.Context, id int) (*Customer, error)
+	List(ctx context.Context, opts ListOptions) (*CustomerIterator, error)
+	Create(ctx context.Context, id int, attributes map[string]string) (*Customer, error)
+	Update(ctx context.Context, id int, attributes map[string]string) (*Customer, error)
+	Delete(ctx context.Context, id int) error
+	TotalCustomers(ctx context.Context) (int, error)
+}
+
+// ListenAndServe starts the HTTP API for the given datastore on addr,
+// applying the deadlines in cfg.
+func ListenAndServe(addr string, ds Datastore, cfg Config) error {
+	e := newEcho(ds, cfg)
+
+	e.Server.Addr = addr
+	e.Server.ReadTimeout = cfg.ReadDeadline
+	e.Server.WriteTimeout = cfg.WriteDeadline
+
+	return e.StartServer(e.Server)
+}
+
+// newEcho builds the routed *echo.Echo instance ListenAndServe serves,
+// split out so tests can exercise routes directly (e.g. via e.ServeHTTP)
+// without binding a real listener.
+func newEcho(ds Datastore, cfg Config) *echo.Echo {
+	e := echo.New()
+
+	e.GET("/customers/count", func(c echo.Context) error {
+		ctx, cancel := withDeadline(c.Request().Context(), cfg.DefaultTimeout)
+		defer cancel()
+
+		total, err := ds.TotalCustomers(ctx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return deadlineExceeded(c)
+			}
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{"total": total})
+	})
+
+	e.GET("/customers", func(c echo.Context) error {
+		opts := ListOptions{PageToken: c.QueryParam("page_token")}
+
+		if pageSize, err := strconv.Atoi(c.QueryParam("page_size")); err == nil {
+			opts.PageSize = pageSize
+		}
+
+		for key, values := range c.QueryParams() {
+			attrName, isAttr := strings.CutPrefix(key, "attr.")
+			if !isAttr || len(values) == 0 {
+				continue
+			}
+
+			if opts.Attributes == nil {
+				opts.Attributes = make(map[string]string)
+			}
+			opts.Attributes[attrName] = values[0]
+		}
+
+		ctx, cancel := withDeadline(c.Request().Context(), cfg.timeoutFor(cfg.ListTimeout))
+		defer cancel()
+
+		it, err := ds.List(ctx, opts)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return deadlineExceeded(c)
+			}
+			return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		}
+
+		customers := make([]*Customer, 0)
+		for {
+			customer, err := it.Next()
+			if errors.Is(err, ErrIteratorDone) {
+				break
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+			}
+
+			customers = append(customers, customer)
+		}
+
+		return c.JSON(http.StatusOK, echo.Map{
+			"customers":       customers,
+			"next_page_token": it.NextPageToken(),
+		})
+	})
+
+	e.GET("/customers/:id", func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid customer id"})
+		}
+
+		ctx, cancel := withDeadline(c.Request().Context(), cfg.DefaultTimeout)
+		defer cancel()
+
+		customer, err := ds.Get(ctx, id)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return deadlineExceeded(c)
+			}
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+
+		return c.JSON(http.StatusOK, customer)
+	})
+
+	e.POST("/customers/:id", func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid customer id"})
+		}
+
+		var attributes map[string]string
+		if err := c.Bind(&attributes); err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+
+		ctx, cancel := withDeadline(c.Request().Context(), cfg.DefaultTimeout)
+		defer cancel()
+
+		customer, err := ds.Create(ctx, id, attributes)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return deadlineExceeded(c)
+			}
+			return c.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+		}
+
+		return c.JSON(http.StatusCreated, customer)
+	})
+
+	e.PUT("/customers/:id", func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid customer id"})
+		}
+
+		var attributes map[string]string
+		if err := c.Bind(&attributes); err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+		}
+
+		ctx, cancel := withDeadline(c.Request().Context(), cfg.DefaultTimeout)
+		defer cancel()
+
+		customer, err := ds.Update(ctx, id, attributes)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return deadlineExceeded(c)
+			}
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+
+		return c.JSON(http.StatusOK, customer)
+	})
+
+	e.DELETE("/customers/:id", func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"error": "invalid customer id"})
+		}
+
+		ctx, cancel := withDeadline(c.Request().Context(), cfg.DefaultTimeout)
+		defer cancel()
+
+		if err := ds.Delete(ctx, id); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return deadlineExceeded(c)
+			}
+			return c.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	return e
+}
@@ -0,0 +1,103 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingDatastore is a Datastore stub whose methods wait on ctx instead of
+// ever returning data, so tests can drive a handler past its configured
+// deadline without a real slow backend.
+type blockingDatastore struct{}
+
+func (blockingDatastore) Get(ctx context.Context, id int) (*Customer, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingDatastore) List(ctx context.Context, opts ListOptions) (*CustomerIterator, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingDatastore) Create(ctx context.Context, id int, attributes map[string]string) (*Customer, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingDatastore) Update(ctx context.Context, id int, attributes map[string]string) (*Customer, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingDatastore) Delete(ctx context.Context, id int) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (blockingDatastore) TotalCustomers(ctx context.Context) (int, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+// assertDeadlineExceeded drives req against e and confirms it comes back as
+// HTTP 503 with the deadline_exceeded body within a generous wall-clock
+// bound, so a handler that fails to honor its deadline fails the test
+// instead of hanging it.
+func assertDeadlineExceeded(t *testing.T, e http.Handler, req *http.Request) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		e.ServeHTTP(rec, req)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return within 1s of its configured deadline")
+	}
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want %d; body: %s", rec.Code, http.StatusServiceUnavailable, rec.Body.String())
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response body was not valid JSON: %v", err)
+	}
+	if body["code"] != "deadline_exceeded" {
+		t.Fatalf("got body %v, want code=deadline_exceeded", body)
+	}
+}
+
+func TestGetReturns503OnDeadlineExceeded(t *testing.T) {
+	e := newEcho(blockingDatastore{}, Config{DefaultTimeout: 10 * time.Millisecond})
+
+	req := httptest.NewRequest(http.MethodGet, "/customers/1", nil)
+	assertDeadlineExceeded(t, e, req)
+}
+
+func TestListReturns503OnDeadlineExceeded(t *testing.T) {
+	e := newEcho(blockingDatastore{}, Config{DefaultTimeout: time.Second, ListTimeout: 10 * time.Millisecond})
+
+	req := httptest.NewRequest(http.MethodGet, "/customers", nil)
+	assertDeadlineExceeded(t, e, req)
+}
+
+// TestListUsesDefaultTimeoutWhenListTimeoutUnset confirms the /customers
+// route falls back to DefaultTimeout - via cfg.timeoutFor - when ListTimeout
+// is left at its zero value.
+func TestListUsesDefaultTimeoutWhenListTimeoutUnset(t *testing.T) {
+	e := newEcho(blockingDatastore{}, Config{DefaultTimeout: 10 * time.Millisecond})
+
+	req := httptest.NewRequest(http.MethodGet, "/customers", nil)
+	assertDeadlineExceeded(t, e, req)
+}
@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// Record is a single line of the customer.io event stream as read from the
+// on-disk message log.
+type Record struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id"`
+	Type      string            `json:"type"`
+	Name      string            `json:"name"`
+	Timestamp int64             `json:"timestamp"`
+	Data      map[string]string `json:"data"`
+
+	// Offset is the byte position immediately following this record (and its
+	// trailing newline) in the source log. It's not part of the wire
+	// format - callers that snapshot progress through the log use it to
+	// know where to resume a later Process call.
+	Offset int64 `json:"-"`
+}
+
+// Process reads newline-delimited JSON records from r and emits them on the
+// returned channel as they're decoded. The channel is closed once r is
+// exhausted, ctx is cancelled, or the scan otherwise stops.
+func Process(ctx context.Context, r io.Reader) (<-chan *Record, error) {
+	out := make(chan *Record)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	// Wrap bufio.ScanLines to capture how many bytes it actually advanced
+	// past for each token, rather than assuming len(line)+1 for the
+	// stripped newline. A final line with no trailing newline advances by
+	// exactly len(line), not len(line)+1; getting this wrong would
+	// overcount offset and, if the file is later appended to past that
+	// point, drop the first byte of the next record on a resumed read.
+	var advance int
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		n, token, err := bufio.ScanLines(data, atEOF)
+		advance = n
+		return n, token, err
+	})
+
+	go func() {
+		defer close(out)
+
+		var offset int64
+
+		for scanner.Scan() {
+			if err := ctx.Err(); err != nil {
+				return
+			}
+
+			line := scanner.Bytes()
+			offset += int64(advance)
+
+			var record Record
+			if err := json.Unmarshal(line, &record); err != nil {
+				continue
+			}
+			record.Offset = offset
+
+			select {
+			case out <- &record:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,84 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func drainRecords(t *testing.T, ch <-chan *Record) []*Record {
+	t.Helper()
+
+	var records []*Record
+	for record := range ch {
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// TestProcessOffsetNoTrailingNewline is a regression test for Offset
+// overcounting when the final line in the source has no trailing newline.
+// It writes a log whose last line is unterminated (the state a snapshot
+// might be taken in while a writer is mid-line), appends one more record
+// past that exact byte position, and confirms seeking to the last record's
+// Offset and resuming Process from there decodes the appended record
+// instead of losing its first byte.
+func TestProcessOffsetNoTrailingNewline(t *testing.T) {
+	first := `{"id":"evt-1","user_id":"1","type":"event","name":"purchased"}`
+	second := `{"id":"evt-2","user_id":"1","type":"event","name":"purchased"}`
+
+	// first line terminated, second line deliberately has no trailing
+	// newline - the in-progress-write state a resumed ingest must tolerate.
+	unterminated := []byte(first + "\n" + second)
+
+	ch, err := Process(context.Background(), bytes.NewReader(unterminated))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	records := drainRecords(t, ch)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	lastOffset := records[1].Offset
+	if lastOffset != int64(len(unterminated)) {
+		t.Fatalf("got offset %d, want %d (exact length of the unterminated log)", lastOffset, len(unterminated))
+	}
+
+	// Simulate the writer appending a third record directly after the
+	// unterminated second line, then resuming from the saved offset.
+	full := append(unterminated, []byte(`{"id":"evt-3","user_id":"1","type":"event","name":"purchased"}`+"\n")...)
+
+	resumeCh, err := Process(context.Background(), bytes.NewReader(full[lastOffset:]))
+	if err != nil {
+		t.Fatalf("Process (resume) failed: %v", err)
+	}
+
+	resumed := drainRecords(t, resumeCh)
+	if len(resumed) != 1 || resumed[0].ID != "evt-3" {
+		t.Fatalf("got %+v, want a single record evt-3 (an overcounted offset would have dropped its first byte)", resumed)
+	}
+}
+
+// TestProcessOffsetTrailingNewline confirms the common case - every line
+// including the last one terminated by '\n' - still reports an offset equal
+// to the exact number of bytes consumed.
+func TestProcessOffsetTrailingNewline(t *testing.T) {
+	data := []byte(`{"id":"evt-1","user_id":"1","type":"event","name":"purchased"}` + "\n")
+
+	ch, err := Process(context.Background(), bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	records := drainRecords(t, ch)
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+
+	if records[0].Offset != int64(len(data)) {
+		t.Fatalf("got offset %d, want %d", records[0].Offset, len(data))
+	}
+}